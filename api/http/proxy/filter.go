@@ -1,78 +1,251 @@
 package proxy
 
-import "github.com/portainer/portainer"
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/swarm"
+	"github.com/portainer/portainer"
+)
+
+type (
+	// portainerMetadata is embedded into decorated Docker API responses to expose the
+	// resource control associated to a resource, if any.
+	portainerMetadata struct {
+		ResourceControl *portainer.ResourceControl `json:"ResourceControl,omitempty"`
+	}
+
+	decoratedVolume struct {
+		types.Volume
+		Portainer portainerMetadata `json:"Portainer"`
+	}
+
+	decoratedContainer struct {
+		types.Container
+		Portainer portainerMetadata `json:"Portainer"`
+	}
+
+	decoratedContainerInspect struct {
+		types.ContainerJSON
+		Portainer portainerMetadata `json:"Portainer"`
+	}
+
+	decoratedService struct {
+		swarm.Service
+		Portainer portainerMetadata `json:"Portainer"`
+	}
+)
 
 // filterVolumeList loops through all volumes, filters volumes without any resource control (public resources) or with
 // any resource control giving access to the user (these volumes will be decorated).
 // Volume object format reference: https://docs.docker.com/engine/api/v1.28/#operation/VolumeList
-func filterVolumeList(volumeData []interface{}, resourceControls []portainer.ResourceControl, userID portainer.UserID, userTeamIDs []portainer.TeamID) ([]interface{}, error) {
-	filteredVolumeData := make([]interface{}, 0)
+func filterVolumeList(volumes []types.Volume, resourceControls []portainer.ResourceControl, userID portainer.UserID, userTeamIDs []portainer.TeamID) ([]decoratedVolume, error) {
+	filteredVolumes := make([]decoratedVolume, 0)
 
-	for _, volume := range volumeData {
-		volumeObject := volume.(map[string]interface{})
-		if volumeObject[volumeIdentifier] == nil {
-			return nil, ErrDockerVolumeIdentifierNotFound
-		}
-
-		volumeID := volumeObject[volumeIdentifier].(string)
-		resourceControl := getResourceControlByResourceID(volumeID, resourceControls)
+	for _, volume := range volumes {
+		resourceControl := getResourceControlByResourceID(volume.Name, resourceControls)
 		if resourceControl == nil {
-			filteredVolumeData = append(filteredVolumeData, volumeObject)
-		} else if resourceControl != nil && canUserAccessResource(userID, userTeamIDs, resourceControl) {
-			volumeObject = decorateObject(volumeObject, resourceControl)
-			filteredVolumeData = append(filteredVolumeData, volumeObject)
+			filteredVolumes = append(filteredVolumes, decoratedVolume{Volume: volume})
+		} else if canUserAccessResource(userID, userTeamIDs, resourceControl) {
+			filteredVolumes = append(filteredVolumes, decoratedVolume{Volume: volume, Portainer: portainerMetadata{ResourceControl: resourceControl}})
 		}
 	}
 
-	return filteredVolumeData, nil
+	return filteredVolumes, nil
 }
 
 // filterContainerList loops through all containers, filters containers without any resource control (public resources) or with
 // any resource control giving access to the user (these containers will be decorated).
 // Container object format reference: https://docs.docker.com/engine/api/v1.28/#operation/ContainerList
-func filterContainerList(containerData []interface{}, resourceControls []portainer.ResourceControl, userID portainer.UserID, userTeamIDs []portainer.TeamID) ([]interface{}, error) {
-	filteredContainerData := make([]interface{}, 0)
+func filterContainerList(containers []types.Container, resourceControls []portainer.ResourceControl, userID portainer.UserID, userTeamIDs []portainer.TeamID) ([]decoratedContainer, error) {
+	filteredContainers := make([]decoratedContainer, 0)
 
-	for _, container := range containerData {
-		containerObject := container.(map[string]interface{})
-		if containerObject[containerIdentifier] == nil {
-			return nil, ErrDockerContainerIdentifierNotFound
-		}
-
-		containerID := containerObject[containerIdentifier].(string)
-		resourceControl := getResourceControlByResourceID(containerID, resourceControls)
+	for _, container := range containers {
+		resourceControl := getResourceControlByResourceID(container.ID, resourceControls)
 		if resourceControl == nil {
-			filteredContainerData = append(filteredContainerData, containerObject)
-		} else if resourceControl != nil && canUserAccessResource(userID, userTeamIDs, resourceControl) {
-			containerObject = decorateObject(containerObject, resourceControl)
-			filteredContainerData = append(filteredContainerData, containerObject)
+			filteredContainers = append(filteredContainers, decoratedContainer{Container: container})
+		} else if canUserAccessResource(userID, userTeamIDs, resourceControl) {
+			filteredContainers = append(filteredContainers, decoratedContainer{Container: container, Portainer: portainerMetadata{ResourceControl: resourceControl}})
 		}
 	}
 
-	return filteredContainerData, nil
+	return filteredContainers, nil
 }
 
 // filterServiceList loops through all services, filters services without any resource control (public resources) or with
 // any resource control giving access to the user (these services will be decorated).
 // Service object format reference: https://docs.docker.com/engine/api/v1.28/#operation/ServiceList
-func filterServiceList(serviceData []interface{}, resourceControls []portainer.ResourceControl, userID portainer.UserID, userTeamIDs []portainer.TeamID) ([]interface{}, error) {
-	filteredServiceData := make([]interface{}, 0)
+func filterServiceList(services []swarm.Service, resourceControls []portainer.ResourceControl, userID portainer.UserID, userTeamIDs []portainer.TeamID) ([]decoratedService, error) {
+	filteredServices := make([]decoratedService, 0)
 
-	for _, service := range serviceData {
-		serviceObject := service.(map[string]interface{})
-		if serviceObject[serviceIdentifier] == nil {
-			return nil, ErrDockerServiceIdentifierNotFound
+	for _, service := range services {
+		resourceControl := getResourceControlByResourceID(service.ID, resourceControls)
+		if resourceControl == nil {
+			filteredServices = append(filteredServices, decoratedService{Service: service})
+		} else if canUserAccessResource(userID, userTeamIDs, resourceControl) {
+			filteredServices = append(filteredServices, decoratedService{Service: service, Portainer: portainerMetadata{ResourceControl: resourceControl}})
 		}
+	}
 
-		serviceID := serviceObject[serviceIdentifier].(string)
-		resourceControl := getResourceControlByResourceID(serviceID, resourceControls)
-		if resourceControl == nil {
-			filteredServiceData = append(filteredServiceData, serviceObject)
-		} else if resourceControl != nil && canUserAccessResource(userID, userTeamIDs, resourceControl) {
-			serviceObject = decorateObject(serviceObject, resourceControl)
-			filteredServiceData = append(filteredServiceData, serviceObject)
+	return filteredServices, nil
+}
+
+// volumeListOperation unmarshals the response as an array of Docker volumes, filters it based on
+// resource controls and rewrites the response with the decorated volumes.
+func volumeListOperation(request *http.Request, response *http.Response, executor *restrictedOperationContext) error {
+	var volumeListResponse struct {
+		Volumes []types.Volume
+	}
+	if err := getResponseAsJSONObject(response, &volumeListResponse); err != nil {
+		return err
+	}
+
+	filteredVolumes, err := filterVolumeList(volumeListResponse.Volumes, executor.resourceControls, executor.userID, executor.userTeamIDs)
+	if err != nil {
+		return err
+	}
+
+	return rewriteResponse(response, struct {
+		Volumes []decoratedVolume
+	}{filteredVolumes}, http.StatusOK)
+}
+
+// volumeInspectOperation unmarshals the response as a Docker volume, verifies that the user
+// has access to it and decorates the response with the associated resource control.
+func volumeInspectOperation(request *http.Request, response *http.Response, executor *restrictedOperationContext) error {
+	var volume types.Volume
+	if err := getResponseAsJSONObject(response, &volume); err != nil {
+		return err
+	}
+
+	decoratedVolume := decoratedVolume{Volume: volume}
+
+	if !executor.isAdmin {
+		resourceControl := getResourceControlByResourceID(volume.Name, executor.resourceControls)
+		if resourceControl != nil {
+			if !canUserAccessResource(executor.userID, executor.userTeamIDs, resourceControl) {
+				return rewriteAccessDeniedResponse(response)
+			}
+			decoratedVolume.Portainer = portainerMetadata{ResourceControl: resourceControl}
+		}
+	}
+
+	return rewriteResponse(response, decoratedVolume, http.StatusOK)
+}
+
+// containerListOperation unmarshals the response as an array of Docker containers, filters it based on
+// resource controls and rewrites the response with the decorated containers.
+func containerListOperation(request *http.Request, response *http.Response, executor *restrictedOperationContext) error {
+	var containers []types.Container
+	if err := getResponseAsJSONObject(response, &containers); err != nil {
+		return err
+	}
+
+	filteredContainers, err := filterContainerList(containers, executor.resourceControls, executor.userID, executor.userTeamIDs)
+	if err != nil {
+		return err
+	}
+
+	return rewriteResponse(response, filteredContainers, http.StatusOK)
+}
+
+// containerInspectOperation unmarshals the response as a Docker container, verifies that the user
+// has access to it and decorates the response with the associated resource control.
+func containerInspectOperation(request *http.Request, response *http.Response, executor *restrictedOperationContext) error {
+	var container types.ContainerJSON
+	if err := getResponseAsJSONObject(response, &container); err != nil {
+		return err
+	}
+
+	decoratedContainer := decoratedContainerInspect{ContainerJSON: container}
+
+	if !executor.isAdmin {
+		resourceControl := getResourceControlByResourceID(container.ID, executor.resourceControls)
+		if resourceControl != nil {
+			if !canUserAccessResource(executor.userID, executor.userTeamIDs, resourceControl) {
+				return rewriteAccessDeniedResponse(response)
+			}
+			decoratedContainer.Portainer = portainerMetadata{ResourceControl: resourceControl}
 		}
 	}
 
-	return filteredServiceData, nil
+	return rewriteResponse(response, decoratedContainer, http.StatusOK)
+}
+
+// serviceListOperation unmarshals the response as an array of Docker services, filters it based on
+// resource controls and rewrites the response with the decorated services.
+func serviceListOperation(request *http.Request, response *http.Response, executor *restrictedOperationContext) error {
+	var services []swarm.Service
+	if err := getResponseAsJSONObject(response, &services); err != nil {
+		return err
+	}
+
+	filteredServices, err := filterServiceList(services, executor.resourceControls, executor.userID, executor.userTeamIDs)
+	if err != nil {
+		return err
+	}
+
+	return rewriteResponse(response, filteredServices, http.StatusOK)
+}
+
+// serviceInspectOperation unmarshals the response as a Docker service, verifies that the user
+// has access to it and decorates the response with the associated resource control.
+func serviceInspectOperation(request *http.Request, response *http.Response, executor *restrictedOperationContext) error {
+	var service swarm.Service
+	if err := getResponseAsJSONObject(response, &service); err != nil {
+		return err
+	}
+
+	decoratedService := decoratedService{Service: service}
+
+	if !executor.isAdmin {
+		resourceControl := getResourceControlByResourceID(service.ID, executor.resourceControls)
+		if resourceControl != nil {
+			if !canUserAccessResource(executor.userID, executor.userTeamIDs, resourceControl) {
+				return rewriteAccessDeniedResponse(response)
+			}
+			decoratedService.Portainer = portainerMetadata{ResourceControl: resourceControl}
+		}
+	}
+
+	return rewriteResponse(response, decoratedService, http.StatusOK)
+}
+
+// getResponseAsJSONObject reads the response body and decodes it into the target value,
+// which should be a pointer to the concrete engine-api type expected for the endpoint.
+func getResponseAsJSONObject(response *http.Response, target interface{}) error {
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	return json.Unmarshal(body, target)
+}
+
+// rewriteResponse replaces the body of a response with the marshalled content and
+// updates the associated headers accordingly.
+func rewriteResponse(response *http.Response, newContent interface{}, statusCode int) error {
+	jsonData, err := json.Marshal(newContent)
+	if err != nil {
+		return err
+	}
+
+	response.StatusCode = statusCode
+	response.Body = ioutil.NopCloser(bytes.NewReader(jsonData))
+	response.ContentLength = int64(len(jsonData))
+	if response.Header != nil {
+		response.Header.Set("Content-Length", strconv.Itoa(len(jsonData)))
+	}
+
+	return nil
+}
+
+// rewriteAccessDeniedResponse rewrites a response to a Docker access denied error.
+func rewriteAccessDeniedResponse(response *http.Response) error {
+	return rewriteResponse(response, map[string]string{"message": ErrResourceAccessDenied.Error()}, http.StatusForbidden)
 }
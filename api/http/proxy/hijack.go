@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/docker/engine-api/types"
+	"github.com/portainer/portainer"
+	"github.com/portainer/portainer/http/context"
+)
+
+// IsHijackedRequest returns true if the request targets an endpoint that upgrades the
+// connection into a raw, bidirectional stream (container attach, exec start). These requests
+// must be handed to HijackRequest instead of the regular RoundTripper-based proxy path.
+func IsHijackedRequest(request *http.Request) bool {
+	requestPath := request.URL.Path
+
+	if request.Method != http.MethodPost {
+		return false
+	}
+
+	if strings.HasSuffix(requestPath, "/attach") {
+		return true
+	}
+
+	return strings.HasPrefix(requestPath, "/exec/") && strings.HasSuffix(requestPath, "/start")
+}
+
+// HijackRequest authorizes a hijacked Docker request (container attach or exec start) against
+// the resource controls associated to the target container and, if allowed, splices the
+// hijacked client connection directly to the Docker socket so that data flows unbuffered in
+// both directions. It is meant to be called by the HTTP layer that owns the original
+// http.ResponseWriter, ahead of the regular reverse-proxy/RoundTripper path, since a hijacked
+// connection cannot be represented as an *http.Response.
+func (p *proxyTransport) HijackRequest(writer http.ResponseWriter, request *http.Request) error {
+	if err := p.authorizeHijackedRequest(request); err != nil {
+		return err
+	}
+
+	dockerConn, err := p.dialDocker(request)
+	if err != nil {
+		return err
+	}
+
+	return hijackRequest(dockerConn, writer, request)
+}
+
+// dialDocker opens a new connection to the underlying Docker environment, reusing the
+// transport's Unix socket dialer when available and falling back to a direct TCP dial
+// otherwise.
+func (p *proxyTransport) dialDocker(request *http.Request) (net.Conn, error) {
+	if p.dockerTransport.Dial != nil {
+		return p.dockerTransport.Dial("", "")
+	}
+
+	return net.Dial("tcp", request.URL.Host)
+}
+
+// hijackRequest takes over the client connection, writes the original request to the Docker
+// connection and splices the two connections together until either side closes.
+func hijackRequest(dockerConn net.Conn, writer http.ResponseWriter, request *http.Request) error {
+	defer dockerConn.Close()
+
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		return ErrResourceHijackNotSupported
+	}
+
+	clientConn, _, err := hijacker.Hijack()
+	if err != nil {
+		return err
+	}
+	defer clientConn.Close()
+
+	if err := request.Write(dockerConn); err != nil {
+		return err
+	}
+
+	errorChan := make(chan error, 2)
+	go streamConnection(dockerConn, clientConn, errorChan)
+	go streamConnection(clientConn, dockerConn, errorChan)
+
+	return <-errorChan
+}
+
+// streamConnection copies data from src to dst without buffering, used to splice a hijacked
+// Docker connection with the original client connection.
+func streamConnection(dst io.Writer, src io.Reader, errorChan chan<- error) {
+	_, err := io.Copy(dst, src)
+	errorChan <- err
+}
+
+// authorizeHijackedRequest resolves the container targeted by an attach or exec request and
+// ensures the current user can access it before the connection is handed over.
+func (p *proxyTransport) authorizeHijackedRequest(request *http.Request) error {
+	containerID, err := p.hijackedRequestContainerID(request)
+	if err != nil {
+		return err
+	}
+
+	tokenData, err := context.GetTokenData(request)
+	if err != nil {
+		return err
+	}
+
+	if tokenData.Role == portainer.AdministratorRole {
+		return nil
+	}
+
+	userTeams, err := p.TeamService.TeamsByUserID(tokenData.ID)
+	if err != nil {
+		return err
+	}
+
+	userTeamIDs := make([]portainer.TeamID, 0)
+	for _, team := range userTeams {
+		userTeamIDs = append(userTeamIDs, team.ID)
+	}
+
+	resourceControls, err := p.ResourceControlService.ResourceControls(portainer.ContainerResourceControl)
+	if err != nil {
+		return err
+	}
+
+	resourceControl := getResourceControlByResourceID(containerID, resourceControls)
+	if !canUserAccessResource(tokenData.ID, userTeamIDs, resourceControl) {
+		return ErrResourceAccessDenied
+	}
+
+	return nil
+}
+
+// hijackedRequestContainerID extracts the container identifier targeted by a container attach
+// or exec start request, resolving it through an exec inspect call in the latter case.
+func (p *proxyTransport) hijackedRequestContainerID(request *http.Request) (string, error) {
+	requestPath := request.URL.Path
+
+	if strings.HasPrefix(requestPath, "/exec/") {
+		execID := strings.TrimSuffix(strings.TrimPrefix(requestPath, "/exec/"), "/start")
+		return p.containerIDFromExecID(request, execID)
+	}
+
+	// assume /containers/{id}/attach
+	return path.Base(path.Dir(requestPath)), nil
+}
+
+// containerIDFromExecID resolves the container associated to a previously created exec
+// instance by querying the Docker exec inspect endpoint.
+func (p *proxyTransport) containerIDFromExecID(request *http.Request, execID string) (string, error) {
+	inspectURL := *request.URL
+	inspectURL.Path = "/exec/" + execID + "/json"
+
+	inspectRequest := &http.Request{
+		Method: http.MethodGet,
+		URL:    &inspectURL,
+		Host:   request.Host,
+		Header: make(http.Header),
+	}
+
+	response, err := p.executeDockerRequest(inspectRequest)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var execInspect types.ContainerExecInspect
+	if err := getResponseAsJSONObject(response, &execInspect); err != nil {
+		return "", err
+	}
+
+	return execInspect.ContainerID, nil
+}
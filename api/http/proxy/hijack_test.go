@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeHijackWriter is a minimal http.ResponseWriter/http.Hijacker whose Hijack method hands
+// back a pre-established net.Conn, letting tests exercise hijackRequest without a real HTTP
+// server.
+type fakeHijackWriter struct {
+	conn net.Conn
+}
+
+func (w *fakeHijackWriter) Header() http.Header         { return http.Header{} }
+func (w *fakeHijackWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *fakeHijackWriter) WriteHeader(statusCode int)  {}
+
+func (w *fakeHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, nil, nil
+}
+
+// TestHijackRequestSplicesBothDirections verifies that hijackRequest relays data written by
+// the Docker daemon to the client and, just as importantly, relays data written by the client
+// (e.g. stdin for an interactive exec) back to the Docker daemon.
+func TestHijackRequestSplicesBothDirections(t *testing.T) {
+	dockerConn, dockerDaemon := net.Pipe()
+	clientConn, clientApp := net.Pipe()
+	defer clientApp.Close()
+	defer dockerDaemon.Close()
+
+	request, err := http.NewRequest(http.MethodPost, "http://docker/containers/abc123/attach?stream=1&stdout=1&stdin=1", nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %s", err)
+	}
+
+	writer := &fakeHijackWriter{conn: clientConn}
+
+	go hijackRequest(dockerConn, writer, request)
+
+	go func() {
+		buffer := make([]byte, 4096)
+		dockerDaemon.Read(buffer)
+		dockerDaemon.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/vnd.docker.raw-stream\r\n\r\nfrom-docker"))
+	}()
+
+	buffer := make([]byte, len("from-docker")+128)
+	n, err := clientApp.Read(buffer)
+	if err != nil {
+		t.Fatalf("unexpected error reading from spliced client connection: %s", err)
+	}
+	if got := string(buffer[:n]); !strings.Contains(got, "from-docker") {
+		t.Fatalf("expected the client side to see the docker response, got %q", got)
+	}
+
+	go clientApp.Write([]byte("from-client"))
+
+	buffer2 := make([]byte, len("from-client"))
+	n2, err := dockerDaemon.Read(buffer2)
+	if err != nil {
+		t.Fatalf("unexpected error reading from spliced docker connection: %s", err)
+	}
+	if string(buffer2[:n2]) != "from-client" {
+		t.Fatalf("expected the docker side to see data written by the client, got %q", string(buffer2[:n2]))
+	}
+}
@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docker/engine-api/types"
+	"github.com/portainer/portainer"
+	"github.com/portainer/portainer/http/context"
+)
+
+// proxyImageRequest intercepts image pull/push, build and distribution requests and, when the
+// targeted image belongs to a private registry the user has access to, decorates the outgoing
+// request with that registry's credentials before forwarding it to Docker. Build requests are
+// handled separately since Docker expects a map of per-registry credentials there rather than
+// a single AuthConfig.
+func (p *proxyTransport) proxyImageRequest(request *http.Request) (*http.Response, error) {
+	var err error
+	if request.URL.Path == "/build" {
+		err = p.attachRegistriesConfig(request)
+	} else {
+		err = p.attachRegistryAuthentication(request)
+	}
+
+	if err == ErrResourceAccessDenied {
+		return writeAccessDeniedResponse()
+	} else if err != nil {
+		return nil, err
+	}
+
+	return p.executeDockerRequest(request)
+}
+
+// attachRegistryAuthentication resolves the registry targeted by the request, ensures the user
+// is allowed to use its credentials and, if so, sets the X-Registry-Auth header Docker expects.
+func (p *proxyTransport) attachRegistryAuthentication(request *http.Request) error {
+	registry, err := p.resolveRegistry(extractImageNameFromRequest(request))
+	if err != nil || registry == nil || !registry.Authentication {
+		return err
+	}
+
+	tokenData, err := context.GetTokenData(request)
+	if err != nil {
+		return err
+	}
+
+	if tokenData.Role != portainer.AdministratorRole {
+		userTeams, err := p.TeamService.TeamsByUserID(tokenData.ID)
+		if err != nil {
+			return err
+		}
+
+		userTeamIDs := make([]portainer.TeamID, 0)
+		for _, team := range userTeams {
+			userTeamIDs = append(userTeamIDs, team.ID)
+		}
+
+		registryResourceControls, err := p.ResourceControlService.ResourceControls(portainer.RegistryResourceControl)
+		if err != nil {
+			return err
+		}
+
+		resourceControl := getResourceControlByResourceID(strconv.Itoa(int(registry.ID)), registryResourceControls)
+		if !canUserAccessResource(tokenData.ID, userTeamIDs, resourceControl) {
+			return ErrResourceAccessDenied
+		}
+	}
+
+	authHeader, err := buildRegistryAuthHeader(registry)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("X-Registry-Auth", authHeader)
+	return nil
+}
+
+// attachRegistriesConfig attaches the X-Registry-Config header Docker's build endpoint expects:
+// a base64-encoded JSON map of registry address to AuthConfig, covering every registry the
+// current user is allowed to use credentials for. Unlike a pull or push, a build can reference
+// any number of registries across its FROM instructions, so every registry the user can access
+// is included rather than trying to resolve a single image reference.
+func (p *proxyTransport) attachRegistriesConfig(request *http.Request) error {
+	tokenData, err := context.GetTokenData(request)
+	if err != nil {
+		return err
+	}
+
+	registries, err := p.RegistryService.Registries()
+	if err != nil {
+		return err
+	}
+
+	var userTeamIDs []portainer.TeamID
+	var registryResourceControls []portainer.ResourceControl
+	if tokenData.Role != portainer.AdministratorRole {
+		userTeams, err := p.TeamService.TeamsByUserID(tokenData.ID)
+		if err != nil {
+			return err
+		}
+		for _, team := range userTeams {
+			userTeamIDs = append(userTeamIDs, team.ID)
+		}
+
+		registryResourceControls, err = p.ResourceControlService.ResourceControls(portainer.RegistryResourceControl)
+		if err != nil {
+			return err
+		}
+	}
+
+	registryConfig := make(map[string]types.AuthConfig)
+	for i := range registries {
+		registry := registries[i]
+		if !registry.Authentication {
+			continue
+		}
+
+		if tokenData.Role != portainer.AdministratorRole {
+			resourceControl := getResourceControlByResourceID(strconv.Itoa(int(registry.ID)), registryResourceControls)
+			if !canUserAccessResource(tokenData.ID, userTeamIDs, resourceControl) {
+				continue
+			}
+		}
+
+		registryConfig[registry.URL] = buildRegistryAuthConfig(&registry)
+	}
+
+	if len(registryConfig) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(registryConfig)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("X-Registry-Config", base64.URLEncoding.EncodeToString(data))
+	return nil
+}
+
+// resolveRegistry looks for the registry whose URL is a prefix of the given image name.
+// It returns a nil registry when the image does not target any of the configured registries,
+// e.g. official images pulled from Docker Hub.
+func (p *proxyTransport) resolveRegistry(imageName string) (*portainer.Registry, error) {
+	if imageName == "" {
+		return nil, nil
+	}
+
+	registries, err := p.RegistryService.Registries()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range registries {
+		registry := registries[i]
+		if strings.HasPrefix(imageName, registry.URL+"/") {
+			return &registry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// extractImageNameFromRequest extracts the name of the image targeted by an image pull, image
+// push, build or distribution inspect request. The {name} segment of Docker's image routes can
+// itself contain slashes (e.g. a private registry host and repository path), so it must be
+// trimmed out of the surrounding path rather than taken as the last path element.
+func extractImageNameFromRequest(request *http.Request) string {
+	requestPath := request.URL.Path
+
+	switch {
+	case requestPath == "/images/create":
+		return request.URL.Query().Get("fromImage")
+	case requestPath == "/build":
+		return request.URL.Query().Get("t")
+	case strings.HasPrefix(requestPath, "/images/") && strings.HasSuffix(requestPath, "/push"):
+		return strings.TrimSuffix(strings.TrimPrefix(requestPath, "/images/"), "/push")
+	default:
+		// assume /distribution/{name}/json
+		return strings.TrimSuffix(strings.TrimPrefix(requestPath, "/distribution/"), "/json")
+	}
+}
+
+// buildRegistryAuthHeader builds the base64-encoded JSON AuthConfig Docker expects in the
+// X-Registry-Auth header.
+func buildRegistryAuthHeader(registry *portainer.Registry) (string, error) {
+	data, err := json.Marshal(buildRegistryAuthConfig(registry))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// buildRegistryAuthConfig builds the AuthConfig Docker expects for a registry. An identity
+// token, when available, is preferred over a password, mirroring the behavior of the Docker
+// engine-api client.
+func buildRegistryAuthConfig(registry *portainer.Registry) types.AuthConfig {
+	authConfig := types.AuthConfig{
+		Username:      registry.Username,
+		Password:      registry.Password,
+		ServerAddress: registry.URL,
+	}
+
+	if registry.IdentityToken != "" {
+		authConfig.IdentityToken = registry.IdentityToken
+		authConfig.Password = ""
+	}
+
+	return authConfig
+}
+
+// PerformRegistryLogin authenticates against a registry using the OAuth2/identity-token flow
+// supported by Docker engine-api, persists the returned identity token and clears the stored
+// password so that subsequent requests authenticate with the token instead. It is meant to be
+// called by the POST /registries/{id}/login handler, ahead of updating the stored registry.
+func PerformRegistryLogin(client *http.Client, registry *portainer.Registry, registryService portainer.RegistryService) error {
+	authConfig := types.AuthConfig{
+		Username:      registry.Username,
+		Password:      registry.Password,
+		ServerAddress: registry.URL,
+	}
+
+	data, err := json.Marshal(authConfig)
+	if err != nil {
+		return err
+	}
+
+	loginRequest, err := http.NewRequest(http.MethodPost, registry.URL+"/oauth2/token", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	loginRequest.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(loginRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var loginResponse struct {
+		IdentityToken string `json:"IdentityToken"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&loginResponse); err != nil {
+		return err
+	}
+
+	registry.IdentityToken = loginResponse.IdentityToken
+	registry.Password = ""
+
+	return registryService.UpdateRegistry(registry.ID, registry)
+}
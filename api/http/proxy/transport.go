@@ -15,6 +15,7 @@ type (
 		dockerTransport        *http.Transport
 		ResourceControlService portainer.ResourceControlService
 		TeamService            portainer.TeamService
+		RegistryService        portainer.RegistryService
 	}
 	restrictedOperationContext struct {
 		isAdmin          bool
@@ -54,16 +55,66 @@ func (p *proxyTransport) proxyDockerRequest(request *http.Request) (*http.Respon
 		return p.proxyServiceRequest(request)
 	} else if strings.HasPrefix(path, "/volumes") {
 		return p.proxyVolumeRequest(request)
+	} else if strings.HasPrefix(path, "/images") || path == "/build" || strings.HasPrefix(path, "/distribution") {
+		return p.proxyImageRequest(request)
 	}
 
 	return p.executeDockerRequest(request)
 }
 
 func (p *proxyTransport) proxyContainerRequest(request *http.Request) (*http.Response, error) {
+	requestPath := request.URL.Path
+
+	if requestPath == "/containers/json" {
+		return p.rewriteOperation(request, containerListOperation, portainer.ContainerResourceControl)
+	}
+
+	if requestPath == "/containers/create" || requestPath == "/containers/prune" {
+		return p.executeDockerRequest(request)
+	}
+
+	// assume /containers/{id}/(action) request
+	if match, _ := path.Match("/containers/*/*", requestPath); match {
+		if request.Method == http.MethodGet && strings.HasSuffix(requestPath, "/json") {
+			return p.rewriteOperation(request, containerInspectOperation, portainer.ContainerResourceControl)
+		}
+
+		containerID := path.Base(path.Dir(requestPath))
+		return p.restrictedOperation(request, containerID, portainer.ContainerResourceControl)
+	}
+
+	// assume /containers/{id} request (e.g. DELETE to remove a container)
+	if match, _ := path.Match("/containers/*", requestPath); match {
+		containerID := path.Base(requestPath)
+		return p.restrictedOperation(request, containerID, portainer.ContainerResourceControl)
+	}
+
 	return p.executeDockerRequest(request)
 }
 
 func (p *proxyTransport) proxyServiceRequest(request *http.Request) (*http.Response, error) {
+	requestPath := request.URL.Path
+
+	if requestPath == "/services" {
+		return p.rewriteOperation(request, serviceListOperation, portainer.ServiceResourceControl)
+	}
+
+	// assume /services/{id} request (GET to inspect, e.g. DELETE to remove a service)
+	if match, _ := path.Match("/services/*", requestPath); match {
+		if request.Method == http.MethodGet {
+			return p.rewriteOperation(request, serviceInspectOperation, portainer.ServiceResourceControl)
+		}
+
+		serviceID := path.Base(requestPath)
+		return p.restrictedOperation(request, serviceID, portainer.ServiceResourceControl)
+	}
+
+	// assume /services/{id}/(action) request (e.g. update, logs)
+	if match, _ := path.Match("/services/*/*", requestPath); match {
+		serviceID := path.Base(path.Dir(requestPath))
+		return p.restrictedOperation(request, serviceID, portainer.ServiceResourceControl)
+	}
+
 	return p.executeDockerRequest(request)
 }
 
@@ -76,22 +127,21 @@ func (p *proxyTransport) proxyVolumeRequest(request *http.Request) (*http.Respon
 		return p.administratorOperation(request)
 
 	case "/volumes":
-		return p.rewriteOperation(request, volumeListOperation)
+		return p.rewriteOperation(request, volumeListOperation, portainer.VolumeResourceControl)
 
 	default:
 		// assume /volumes/{name}
 		if request.Method == http.MethodGet {
-			p.rewriteOperation(request, volumeInspectOperation)
+			return p.rewriteOperation(request, volumeInspectOperation, portainer.VolumeResourceControl)
 		}
 		volumeID := path.Base(requestPath)
-		p.restrictedOperation(request, volumeID)
+		return p.restrictedOperation(request, volumeID, portainer.VolumeResourceControl)
 	}
-	return p.executeDockerRequest(request)
 }
 
 // restrictedOperation ensures that the current user has the required authorizations
 // before executing the original request.
-func (p *proxyTransport) restrictedOperation(request *http.Request, resourceID string) (*http.Response, error) {
+func (p *proxyTransport) restrictedOperation(request *http.Request, resourceID string, resourceType portainer.ResourceControlType) (*http.Response, error) {
 	var err error
 	tokenData, err := context.GetTokenData(request)
 	if err != nil {
@@ -110,13 +160,13 @@ func (p *proxyTransport) restrictedOperation(request *http.Request, resourceID s
 			userTeamIDs = append(userTeamIDs, team.ID)
 		}
 
-		volumeResourceControls, err := p.ResourceControlService.ResourceControls(portainer.VolumeResourceControl)
+		resourceControls, err := p.ResourceControlService.ResourceControls(resourceType)
 		if err != nil {
 			return nil, err
 		}
 
-		volumeResourceControl := getResourceControlByResourceID(resourceID, volumeResourceControls)
-		if !canUserAccessResource(tokenData.ID, userTeamIDs, volumeResourceControl) {
+		resourceControl := getResourceControlByResourceID(resourceID, resourceControls)
+		if !canUserAccessResource(tokenData.ID, userTeamIDs, resourceControl) {
 			return writeAccessDeniedResponse()
 		}
 	}
@@ -126,14 +176,14 @@ func (p *proxyTransport) restrictedOperation(request *http.Request, resourceID s
 
 // rewriteOperation will create a new operation context with data that will be used
 // to decorate the original request's response.
-func (p *proxyTransport) rewriteOperation(request *http.Request, operation restrictedOperationRequest) (*http.Response, error) {
+func (p *proxyTransport) rewriteOperation(request *http.Request, operation restrictedOperationRequest, resourceType portainer.ResourceControlType) (*http.Response, error) {
 	var err error
 	tokenData, err := context.GetTokenData(request)
 	if err != nil {
 		return nil, err
 	}
 
-	volumeResourceControls, err := p.ResourceControlService.ResourceControls(portainer.VolumeResourceControl)
+	resourceControls, err := p.ResourceControlService.ResourceControls(resourceType)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +191,7 @@ func (p *proxyTransport) rewriteOperation(request *http.Request, operation restr
 	operationContext := &restrictedOperationContext{
 		isAdmin:          true,
 		userID:           tokenData.ID,
-		resourceControls: volumeResourceControls,
+		resourceControls: resourceControls,
 	}
 
 	if tokenData.Role != portainer.AdministratorRole {
@@ -181,4 +231,4 @@ func (p *proxyTransport) administratorOperation(request *http.Request) (*http.Re
 	}
 
 	return p.executeDockerRequest(request)
-}
\ No newline at end of file
+}
@@ -88,6 +88,21 @@ type (
 		Role     UserRole
 	}
 
+	// RegistryID represents a registry identifier.
+	RegistryID int
+
+	// Registry represents a Docker registry with all the info required to connect to it.
+	Registry struct {
+		ID             RegistryID `json:"Id"`
+		Name           string     `json:"Name"`
+		URL            string     `json:"URL"`
+		Authentication bool       `json:"Authentication"`
+		Username       string     `json:"Username"`
+		Password       string     `json:"Password,omitempty"`
+		IdentityToken  string     `json:"IdentityToken,omitempty"`
+		TokenExpiry    int64      `json:"TokenExpiry,omitempty"`
+	}
+
 	// EndpointID represents an endpoint identifier.
 	EndpointID int
 
@@ -132,6 +147,10 @@ type (
 	// It can be either a TLS CA file, a TLS certificate file or a TLS key file.
 	TLSFileType int
 
+	// ResourceControlType represents the type of resource associated to a resource control.
+	// It can be either a container, a service, a volume or a registry resource.
+	ResourceControlType int
+
 	// CLIService represents a service for managing CLI.
 	CLIService interface {
 		ParseFlags(version string) (*CLIFlags, error)
@@ -203,11 +222,20 @@ type (
 
 	// ResourceControlService represents a service for managing resource control data.
 	ResourceControlService interface {
-		ResourceControls() ([]ResourceControl, error)
+		ResourceControls(resourceType ResourceControlType) ([]ResourceControl, error)
 		CreateResourceControl(rc *ResourceControl) error
 		DeleteResourceControl(ID ResourceControlID) error
 	}
 
+	// RegistryService represents a service for managing registry data.
+	RegistryService interface {
+		Registry(ID RegistryID) (*Registry, error)
+		Registries() ([]Registry, error)
+		CreateRegistry(registry *Registry) error
+		UpdateRegistry(ID RegistryID, registry *Registry) error
+		DeleteRegistry(ID RegistryID) error
+	}
+
 	// CryptoService represents a service for encrypting/hashing data.
 	CryptoService interface {
 		Hash(data string) (string, error)
@@ -271,3 +299,15 @@ const (
 	// RestrictedResourceAccessLevel represents a restricted access level on a resource (private ownership)
 	RestrictedResourceAccessLevel
 )
+
+const (
+	_ ResourceControlType = iota
+	// ContainerResourceControl represents a resource control associated to a Docker container
+	ContainerResourceControl
+	// ServiceResourceControl represents a resource control associated to a Docker service
+	ServiceResourceControl
+	// VolumeResourceControl represents a resource control associated to a Docker volume
+	VolumeResourceControl
+	// RegistryResourceControl represents a resource control associated to a registry
+	RegistryResourceControl
+)